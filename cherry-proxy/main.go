@@ -0,0 +1,107 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+// Command cherry-proxy terminates raw OpenFlow TCP from switches (its
+// switch-facing listener is plain TCP; it does not accept TLS) and
+// multiplexes those sessions over a persistent, authenticated connection to
+// a pool of upstream cherry controllers. Running one of these close to a
+// cluster of switches keeps echo/keepalive round trips short, while the
+// northbound apps stay centralized on the controllers; if the upstream it's
+// currently connected to goes away, it fails over to the next one in the
+// pool instead of dropping every south-side session.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"git.sds.co.kr/cherry.git/cherryd/proxy"
+)
+
+// reconnectBackoff bounds how fast cherry-proxy retries the upstream pool
+// after every member has failed, so a pool-wide outage doesn't spin.
+const reconnectBackoff = 5 * time.Second
+
+func main() {
+	listenAddr := flag.String("listen", ":6633", "address to accept switch connections on")
+	upstreamAddrs := flag.String("upstream", "", "comma-separated addresses of the upstream cherry controllers' proxy ports, tried in order with failover")
+	certFile := flag.String("cert", "", "path to this proxy's TLS client certificate")
+	keyFile := flag.String("key", "", "path to this proxy's TLS client key")
+	caFile := flag.String("ca", "", "path to the CA used to verify upstream controller certificates")
+	flag.Parse()
+
+	upstreams := splitUpstreams(*upstreamAddrs)
+	if len(upstreams) == 0 {
+		log.Fatal("-upstream is required")
+	}
+	if *certFile == "" || *keyFile == "" || *caFile == "" {
+		log.Fatal("-cert, -key and -ca are all required to authenticate to upstream controllers")
+	}
+	tlsConfig := proxy.UpstreamTLSConfig{
+		CertFile: *certFile,
+		KeyFile:  *keyFile,
+		CAFile:   *caFile,
+	}
+
+	ln, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatalf("failed to listen on %v: %v", *listenAddr, err)
+	}
+	log.Printf("cherry-proxy listening on %v, forwarding to %v", *listenAddr, upstreams)
+
+	hub := proxy.NewHub()
+	go runUpstream(hub, upstreams, tlsConfig)
+
+	for {
+		south, err := ln.Accept()
+		if err != nil {
+			log.Printf("accept failed: %v", err)
+			continue
+		}
+		go func() {
+			defer south.Close()
+			if err := hub.Serve(south); err != nil {
+				log.Printf("session ended: %v", err)
+			}
+		}()
+	}
+}
+
+// runUpstream keeps the Hub connected to one of upstreams forever, failing
+// over to the next address in the pool and reconnecting after a backoff
+// whenever the current connection is lost.
+func runUpstream(hub *proxy.Hub, upstreams []string, tlsConfig proxy.UpstreamTLSConfig) {
+	for {
+		north, err := proxy.DialUpstream(upstreams, tlsConfig)
+		if err != nil {
+			log.Printf("%v, retrying in %v", err, reconnectBackoff)
+			time.Sleep(reconnectBackoff)
+			continue
+		}
+
+		log.Printf("connected to upstream controller %v", north.RemoteAddr())
+		hub.SetNorth(north)
+		if err := hub.Dispatch(north); err != nil {
+			log.Printf("upstream connection lost: %v", err)
+		}
+		north.Close()
+	}
+}
+
+func splitUpstreams(addrs string) []string {
+	var upstreams []string
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			upstreams = append(upstreams, addr)
+		}
+	}
+	return upstreams
+}
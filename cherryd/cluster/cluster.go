@@ -0,0 +1,29 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+// Package cluster is the cherryd-side import path for the cluster
+// coordination types. It used to carry its own copy of the Lease/Registry/
+// Finder interfaces, which diverged from the one northbound/app imports as
+// github.com/superkkt/cherry/cluster and couldn't share a concrete Registry
+// with it. It now just aliases that package, so a single EtcdRegistry
+// satisfies both import paths and the device package and the northbound
+// apps can be handed the very same instance.
+package cluster
+
+import (
+	root "github.com/superkkt/cherry/cluster"
+)
+
+type Lease = root.Lease
+type Registry = root.Registry
+type Finder = root.Finder
+
+var (
+	SwitchKey    = root.SwitchKey
+	SingletonKey = root.SingletonKey
+	NewFinder    = root.NewFinder
+)
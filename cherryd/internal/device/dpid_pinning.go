@@ -0,0 +1,64 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package device
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sync"
+)
+
+// DPIDPinning maps a DPID to the client-certificate identity (its CN, or
+// one of its SANs) a switch must present to claim that DPID in
+// FEATURES_REPLY. A switch whose cert doesn't match its claimed DPID is
+// almost certainly misconfigured or spoofing another switch's identity, and
+// its connection is dropped.
+type DPIDPinning struct {
+	mu     sync.RWMutex
+	byDPID map[uint64]string // dpid -> expected CN/SAN
+}
+
+func NewDPIDPinning() *DPIDPinning {
+	return &DPIDPinning{
+		byDPID: make(map[uint64]string),
+	}
+}
+
+// Pin records that dpid may only connect using a certificate whose CN or
+// SAN equals identity.
+func (p *DPIDPinning) Pin(dpid uint64, identity string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byDPID[dpid] = identity
+}
+
+// Verify returns an error if cert's CN and SANs don't include the identity
+// pinned to dpid. A DPID with no pin configured is allowed through
+// unchecked, so pinning can be adopted incrementally.
+func (p *DPIDPinning) Verify(dpid uint64, cert *x509.Certificate) error {
+	p.mu.RLock()
+	identity, pinned := p.byDPID[dpid]
+	p.mu.RUnlock()
+
+	if !pinned {
+		return nil
+	}
+	if cert == nil {
+		return fmt.Errorf("dpid %v requires a pinned client certificate but none was presented", dpid)
+	}
+	if cert.Subject.CommonName == identity {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if name == identity {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("dpid %v presented certificate identity %q, expected %q", dpid, cert.Subject.CommonName, identity)
+}
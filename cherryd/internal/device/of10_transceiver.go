@@ -9,27 +9,100 @@ package device
 
 import (
 	"errors"
+	"git.sds.co.kr/cherry.git/cherryd/cluster"
 	"git.sds.co.kr/cherry.git/cherryd/openflow"
 	"git.sds.co.kr/cherry.git/cherryd/openflow/of10"
 	"golang.org/x/net/context"
+	"sync/atomic"
 	"time"
 )
 
+// switchLeaseTTL bounds how long a follower controller waits before it may
+// try to take over a switch whose leader has gone silent.
+const switchLeaseTTL = 10 * time.Second
+
 type OF10Transceiver struct {
 	BaseTransceiver
 	version uint8
+
+	// registry is nil when the controller is running standalone (no
+	// cluster configured), in which case this node always owns every
+	// switch it is connected to.
+	registry cluster.Registry
+	// active is 1 once this node holds the lease for the connected
+	// switch's DPID. Only the active node installs flows or answers
+	// PACKET_INs; other nodes keep the TCP session open as a warm standby.
+	active int32
+	// ctx is the Run context, kept around so handleFeaturesReply (which
+	// only sees the message, not the context) can start the lease
+	// acquisition goroutine.
+	ctx context.Context
+	// pinning is nil when no per-switch client-cert pinning is configured.
+	pinning *DPIDPinning
 }
 
-func NewOF10Transceiver(stream *openflow.Stream, log Logger) *OF10Transceiver {
+func NewOF10Transceiver(stream *openflow.Stream, log Logger, registry cluster.Registry, pinning *DPIDPinning) *OF10Transceiver {
 	return &OF10Transceiver{
 		BaseTransceiver: BaseTransceiver{
 			stream: stream,
 			log:    log,
 		},
-		version: openflow.Ver10,
+		version:  openflow.Ver10,
+		registry: registry,
+		pinning:  pinning,
 	}
 }
 
+// IsActive reports whether this node is currently the leader for the
+// connected switch. Packet-in and flow installation paths should consult
+// this before acting; a standby should only keep the connection warm.
+func (r *OF10Transceiver) IsActive() bool {
+	return r.registry == nil || atomic.LoadInt32(&r.active) == 1
+}
+
+// acquireSwitchLease campaigns for ownership of this switch's DPID in the
+// shared registry, and keeps retrying in the background after a loss so a
+// standby can promote itself the moment the previous leader drops out.
+func (r *OF10Transceiver) acquireSwitchLease(ctx context.Context, dpid uint64) {
+	if r.registry == nil {
+		atomic.StoreInt32(&r.active, 1)
+		return
+	}
+
+	key := cluster.SwitchKey(dpid)
+	go func() {
+		for {
+			lease, err := r.registry.Acquire(ctx, key, switchLeaseTTL)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				r.log.Printf("failed to acquire switch lease for dpid=%v: %v", dpid, err)
+				time.Sleep(switchLeaseTTL)
+				continue
+			}
+
+			atomic.StoreInt32(&r.active, 1)
+			r.log.Printf("promoted to active controller for dpid=%v", dpid)
+			// A freshly promoted node sends a barrier request. IsActive
+			// already reports true before the barrier reply arrives, and
+			// the switch's flow entries are not re-read.
+			if err := r.sendBarrierRequest(); err != nil {
+				r.log.Printf("failed to resend barrier after promotion: %v", err)
+			}
+
+			select {
+			case <-lease.Released():
+				atomic.StoreInt32(&r.active, 0)
+				r.log.Printf("lost switch lease for dpid=%v, standing by", dpid)
+			case <-ctx.Done():
+				lease.Release()
+				return
+			}
+		}
+	}()
+}
+
 func (r *OF10Transceiver) sendHello() error {
 	hello := openflow.NewHello(r.version, r.getTransactionID())
 	return openflow.WriteMessage(r.stream, hello)
@@ -40,13 +113,33 @@ func (r *OF10Transceiver) sendFeaturesRequest() error {
 	return openflow.WriteMessage(r.stream, feature)
 }
 
+func (r *OF10Transceiver) sendBarrierRequest() error {
+	barrier := of10.NewBarrierRequest(r.getTransactionID())
+	return openflow.WriteMessage(r.stream, barrier)
+}
+
 func (r *OF10Transceiver) handleFeaturesReply(msg openflow.Message) error {
 	reply, ok := msg.(*of10.FeaturesReply)
 	if !ok {
 		panic("unexpected message structure type!")
 	}
+	if r.pinning != nil {
+		if err := r.pinning.Verify(reply.DPID, r.stream.PeerCertificate()); err != nil {
+			r.log.Printf("rejecting features_reply: %v", err)
+			return err
+		}
+	}
+
 	r.device = addTransceiver(reply.DPID, 0, r)
-	// TODO: set device's nBuffers and nTables
+	r.device.SetNumBuffers(reply.NBuffers)
+	r.device.SetNumTables(reply.NTables)
+	r.acquireSwitchLease(r.ctx, reply.DPID)
+	// When this transceiver is running over a cherry-proxy session rather
+	// than a direct TCP/TLS socket, the session doesn't know the DPID until
+	// now; without this, every frame it forwards north would carry DPID 0.
+	if sess, ok := r.stream.Conn().(*proxySession); ok {
+		sess.SetDPID(reply.DPID)
+	}
 
 	// XXX: debugging
 	r.log.Printf("FeaturesReply: %+v", reply)
@@ -68,6 +161,12 @@ func (r *OF10Transceiver) handleMessage(msg openflow.Message) error {
 	case of10.OFPT_FEATURES_REPLY:
 		return r.handleFeaturesReply(msg)
 	default:
+		// Every other message type (PACKET_IN, FLOW_REMOVED, ...) is a
+		// switch asking the controller to actually manage it; a standby
+		// has nothing to act on until it's promoted.
+		if !r.IsActive() {
+			return nil
+		}
 		r.log.Printf("Unsupported message type: version=%v, type=%v", header.Version, header.Type)
 		return nil
 	}
@@ -84,6 +183,7 @@ func (r *OF10Transceiver) cleanup() {
 }
 
 func (r *OF10Transceiver) Run(ctx context.Context) {
+	r.ctx = ctx
 	defer r.cleanup()
 	r.stream.SetReadTimeout(1 * time.Second)
 	r.stream.SetWriteTimeout(5 * time.Second)
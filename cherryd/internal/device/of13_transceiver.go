@@ -0,0 +1,325 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package device
+
+import (
+	"errors"
+	"git.sds.co.kr/cherry.git/cherryd/cluster"
+	"git.sds.co.kr/cherry.git/cherryd/openflow"
+	"git.sds.co.kr/cherry.git/cherryd/openflow/of13"
+	"golang.org/x/net/context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OF13Transceiver speaks OpenFlow 1.3, including its multi-table pipeline
+// and group table extensions. It mirrors OF10Transceiver's structure,
+// including its own copy of cluster leader election and DPID/client-cert
+// pinning, and shares BaseTransceiver's connection handling with it.
+type OF13Transceiver struct {
+	BaseTransceiver
+	version uint8
+
+	// registry is nil when the controller is running standalone (no
+	// cluster configured), in which case this node always owns every
+	// switch it is connected to.
+	registry cluster.Registry
+	// active is 1 once this node holds the lease for the connected
+	// switch's DPID. Only the active node installs flows or answers
+	// PACKET_INs; other nodes keep the TCP session open as a warm standby.
+	active int32
+	// ctx is the Run context, kept around so handleFeaturesReply (which
+	// only sees the message, not the context) can start the lease
+	// acquisition goroutine.
+	ctx context.Context
+	// pinning is nil when no per-switch client-cert pinning is configured.
+	pinning *DPIDPinning
+
+	mu sync.Mutex
+	// tables holds the per-table stats reported by the switch, indexed by
+	// table ID, once the OFPMP_TABLE multipart reply arrives.
+	tables map[uint8]of13.TableStats
+	// groups holds the group table descriptions reported by the switch,
+	// indexed by group ID, once the OFPMP_GROUP_DESC multipart reply
+	// arrives.
+	groups map[uint32]of13.GroupDesc
+}
+
+func NewOF13Transceiver(stream *openflow.Stream, log Logger, registry cluster.Registry, pinning *DPIDPinning) *OF13Transceiver {
+	return &OF13Transceiver{
+		BaseTransceiver: BaseTransceiver{
+			stream: stream,
+			log:    log,
+		},
+		version:  openflow.Ver13,
+		registry: registry,
+		pinning:  pinning,
+		tables:   make(map[uint8]of13.TableStats),
+		groups:   make(map[uint32]of13.GroupDesc),
+	}
+}
+
+// IsActive reports whether this node is currently the leader for the
+// connected switch. Packet-in and flow installation paths should consult
+// this before acting; a standby should only keep the connection warm.
+func (r *OF13Transceiver) IsActive() bool {
+	return r.registry == nil || atomic.LoadInt32(&r.active) == 1
+}
+
+// acquireSwitchLease campaigns for ownership of this switch's DPID in the
+// shared registry, and keeps retrying in the background after a loss so a
+// standby can promote itself the moment the previous leader drops out.
+func (r *OF13Transceiver) acquireSwitchLease(ctx context.Context, dpid uint64) {
+	if r.registry == nil {
+		atomic.StoreInt32(&r.active, 1)
+		return
+	}
+
+	key := cluster.SwitchKey(dpid)
+	go func() {
+		for {
+			lease, err := r.registry.Acquire(ctx, key, switchLeaseTTL)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				r.log.Printf("failed to acquire switch lease for dpid=%v: %v", dpid, err)
+				time.Sleep(switchLeaseTTL)
+				continue
+			}
+
+			atomic.StoreInt32(&r.active, 1)
+			r.log.Printf("promoted to active controller for dpid=%v", dpid)
+			// A freshly promoted node sends a barrier request and
+			// re-requests table stats and group descriptions. IsActive
+			// already reports true before any of those replies arrive,
+			// and the switch's flow entries are not re-read.
+			if err := r.sendBarrierRequest(); err != nil {
+				r.log.Printf("failed to resend barrier after promotion: %v", err)
+			}
+			if err := r.sendTableStatsRequest(); err != nil {
+				r.log.Printf("failed to resend table stats request after promotion: %v", err)
+			}
+			if err := r.sendGroupDescStatsRequest(); err != nil {
+				r.log.Printf("failed to resend group desc stats request after promotion: %v", err)
+			}
+
+			select {
+			case <-lease.Released():
+				atomic.StoreInt32(&r.active, 0)
+				r.log.Printf("lost switch lease for dpid=%v, standing by", dpid)
+			case <-ctx.Done():
+				lease.Release()
+				return
+			}
+		}
+	}()
+}
+
+func (r *OF13Transceiver) sendHello() error {
+	hello := openflow.NewHello(r.version, r.getTransactionID())
+	return openflow.WriteMessage(r.stream, hello)
+}
+
+func (r *OF13Transceiver) sendFeaturesRequest() error {
+	feature := of13.NewFeaturesRequest(r.getTransactionID())
+	return openflow.WriteMessage(r.stream, feature)
+}
+
+func (r *OF13Transceiver) sendTableStatsRequest() error {
+	req := of13.NewTableStatsRequest(r.getTransactionID())
+	return openflow.WriteMessage(r.stream, req)
+}
+
+func (r *OF13Transceiver) sendGroupDescStatsRequest() error {
+	req := of13.NewGroupDescStatsRequest(r.getTransactionID())
+	return openflow.WriteMessage(r.stream, req)
+}
+
+func (r *OF13Transceiver) sendBarrierRequest() error {
+	barrier := of13.NewBarrierRequest(r.getTransactionID())
+	return openflow.WriteMessage(r.stream, barrier)
+}
+
+func (r *OF13Transceiver) handleFeaturesReply(msg openflow.Message) error {
+	reply, ok := msg.(*of13.FeaturesReply)
+	if !ok {
+		panic("unexpected message structure type!")
+	}
+	if r.pinning != nil {
+		if err := r.pinning.Verify(reply.DPID, r.stream.PeerCertificate()); err != nil {
+			r.log.Printf("rejecting features_reply: %v", err)
+			return err
+		}
+	}
+
+	r.device = addTransceiver(reply.DPID, 0, r)
+	r.device.SetNumBuffers(reply.NBuffers)
+	r.device.SetNumTables(reply.NTables)
+	r.acquireSwitchLease(r.ctx, reply.DPID)
+	// When this transceiver is running over a cherry-proxy session rather
+	// than a direct TCP/TLS socket, the session doesn't know the DPID until
+	// now; without this, every frame it forwards north would carry DPID 0.
+	if sess, ok := r.stream.Conn().(*proxySession); ok {
+		sess.SetDPID(reply.DPID)
+	}
+
+	// A standby isn't going to act on table/group state, and will get a
+	// fresh copy resent the moment it's actually promoted (see
+	// acquireSwitchLease), so there's no reason to poll for it yet.
+	if r.IsActive() {
+		if err := r.sendTableStatsRequest(); err != nil {
+			return err
+		}
+		if err := r.sendGroupDescStatsRequest(); err != nil {
+			return err
+		}
+	}
+
+	// XXX: debugging
+	r.log.Printf("FeaturesReply: %+v", reply)
+
+	return nil
+}
+
+func (r *OF13Transceiver) handleMultipartReply(msg openflow.Message) error {
+	reply, ok := msg.(*of13.MultipartReply)
+	if !ok {
+		panic("unexpected message structure type!")
+	}
+
+	// A standby doesn't make flow/group decisions off this switch's
+	// pipeline state, so there's nothing to gain from tracking it; it'll
+	// request and store a fresh copy once it's actually promoted.
+	if !r.IsActive() {
+		return nil
+	}
+
+	switch reply.Type {
+	case of13.OFPMP_TABLE:
+		stats, err := of13.ParseTableStats(reply.Body)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		for _, s := range stats {
+			r.tables[s.TableID] = s
+		}
+		r.mu.Unlock()
+	case of13.OFPMP_GROUP_DESC:
+		groups, err := of13.ParseGroupDescStats(reply.Body)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		for _, g := range groups {
+			r.groups[g.GroupID] = g
+		}
+		r.mu.Unlock()
+	default:
+		r.log.Printf("Unsupported multipart reply type: %v", reply.Type)
+	}
+
+	return nil
+}
+
+func (r *OF13Transceiver) handleMessage(msg openflow.Message) error {
+	header := msg.Header()
+	if header.Version != r.version {
+		return errors.New("unexpected openflow protocol version!")
+	}
+
+	switch header.Type {
+	case of13.OFPT_ECHO_REQUEST:
+		return r.handleEchoRequest(msg)
+	case of13.OFPT_ECHO_REPLY:
+		return r.handleEchoReply(msg)
+	case of13.OFPT_FEATURES_REPLY:
+		return r.handleFeaturesReply(msg)
+	case of13.OFPT_MULTIPART_REPLY:
+		return r.handleMultipartReply(msg)
+	default:
+		// Every other message type (PACKET_IN, FLOW_REMOVED, ...) is a
+		// switch asking the controller to actually manage it; a standby
+		// has nothing to act on until it's promoted.
+		if !r.IsActive() {
+			return nil
+		}
+		r.log.Printf("Unsupported message type: version=%v, type=%v", header.Version, header.Type)
+		return nil
+	}
+}
+
+func (r *OF13Transceiver) cleanup() {
+	if r.device == nil {
+		return
+	}
+
+	if r.device.RemoveTransceiver(0) == 0 {
+		Pool.remove(r.device.dpid)
+	}
+}
+
+func (r *OF13Transceiver) Run(ctx context.Context) {
+	r.ctx = ctx
+	defer r.cleanup()
+	r.stream.SetReadTimeout(1 * time.Second)
+	r.stream.SetWriteTimeout(5 * time.Second)
+
+	if err := r.sendHello(); err != nil {
+		r.log.Printf("Failed to send hello message: %v", err)
+		return
+	}
+	if err := r.sendFeaturesRequest(); err != nil {
+		r.log.Printf("Failed to send features_request message: %v", err)
+		return
+	}
+	// TODO: send barrier
+
+	go r.pinger(ctx)
+
+	// Reader goroutine
+	receivedMsg := make(chan openflow.Message)
+	go func() {
+		for {
+			msg, err := openflow.ReadMessage(r.stream)
+			if err != nil {
+				switch {
+				case openflow.IsTimeout(err):
+					// Ignore timeout error
+					continue
+				case err == openflow.ErrUnsupportedMessage:
+					r.log.Print(err)
+					continue
+				default:
+					r.log.Print(err)
+					close(receivedMsg)
+					return
+				}
+			}
+			receivedMsg <- msg
+		}
+	}()
+
+	// Infinite loop
+	for {
+		select {
+		case msg, ok := <-receivedMsg:
+			if !ok {
+				return
+			}
+			if err := r.handleMessage(msg); err != nil {
+				r.log.Print(err)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
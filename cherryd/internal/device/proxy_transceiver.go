@@ -0,0 +1,165 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package device
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.sds.co.kr/cherry.git/cherryd/proxy"
+)
+
+// ProxyListener accepts a single north-side connection from a cherry-proxy
+// and demultiplexes it back into one net.Conn per south-side switch
+// session, so the existing NewTransceiver/NewOF10Transceiver construction
+// path can be reused unmodified: each session still looks like a plain
+// net.Conn to the rest of the device package, it just happens to be
+// tunnelled through a proxy instead of being a direct TCP socket.
+type ProxyListener struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	sessions map[uint64]*proxySession
+
+	// Sessions yields a proxySession the first time a frame for a new
+	// session ID arrives.
+	Sessions chan net.Conn
+}
+
+// NewProxyListener starts demultiplexing frames arriving on conn, which
+// must be the north-side connection accepted from a cherry-proxy.
+func NewProxyListener(conn net.Conn) *ProxyListener {
+	l := &ProxyListener{
+		conn:     conn,
+		sessions: make(map[uint64]*proxySession),
+		Sessions: make(chan net.Conn),
+	}
+	go l.run()
+
+	return l
+}
+
+func (l *ProxyListener) run() {
+	for {
+		frame, err := proxy.ReadFrame(l.conn)
+		if err != nil {
+			l.closeAll()
+			return
+		}
+
+		l.mu.Lock()
+		session, ok := l.sessions[frame.SessionID]
+		if !ok {
+			session = newProxySession(l.conn, frame.SessionID)
+			l.sessions[frame.SessionID] = session
+		}
+		l.mu.Unlock()
+
+		if !ok {
+			l.Sessions <- session
+		}
+		session.deliver(frame.Payload)
+	}
+}
+
+func (l *ProxyListener) closeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.sessions {
+		s.closeLocally()
+	}
+}
+
+// proxySession implements net.Conn on top of a proxy.Frame stream, so a
+// BaseTransceiver can be built from it exactly as it would from a direct
+// TCP connection: reads drain frames tagged with this session's ID, and
+// writes re-frame the payload and send it back up the same north
+// connection, which the upstream cherry-proxy then routes back down to
+// the correct south-side switch socket.
+type proxySession struct {
+	north     net.Conn
+	sessionID uint64
+	dpid      uint64
+
+	incoming chan []byte
+	pending  []byte
+	closed   chan struct{}
+}
+
+func newProxySession(north net.Conn, sessionID uint64) *proxySession {
+	return &proxySession{
+		north:     north,
+		sessionID: sessionID,
+		incoming:  make(chan []byte, 16),
+		closed:    make(chan struct{}),
+	}
+}
+
+// SetDPID records the DPID this session's switch reported in its
+// FEATURES_REPLY, so subsequent Writes are tagged with it instead of zero.
+func (s *proxySession) SetDPID(dpid uint64) {
+	atomic.StoreUint64(&s.dpid, dpid)
+}
+
+func (s *proxySession) deliver(payload []byte) {
+	select {
+	case s.incoming <- payload:
+	case <-s.closed:
+	}
+}
+
+func (s *proxySession) Read(b []byte) (int, error) {
+	if len(s.pending) == 0 {
+		select {
+		case p, ok := <-s.incoming:
+			if !ok {
+				return 0, errors.New("proxy session closed")
+			}
+			s.pending = p
+		case <-s.closed:
+			return 0, errors.New("proxy session closed")
+		}
+	}
+
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write sends SendMessage's payload back through the same proxy hop this
+// session arrived on, tagged with its DPID and with its session ID, which
+// the proxy uses to route it to the right south-side TCP connection.
+func (s *proxySession) Write(b []byte) (int, error) {
+	dpid := atomic.LoadUint64(&s.dpid)
+	if err := proxy.WriteFrame(s.north, proxy.Frame{SessionID: s.sessionID, DPID: dpid, Payload: b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (s *proxySession) closeLocally() {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+func (s *proxySession) Close() error {
+	s.closeLocally()
+	return nil
+}
+
+func (s *proxySession) LocalAddr() net.Addr                { return s.north.LocalAddr() }
+func (s *proxySession) RemoteAddr() net.Addr               { return s.north.RemoteAddr() }
+func (s *proxySession) SetDeadline(t time.Time) error      { return nil }
+func (s *proxySession) SetReadDeadline(t time.Time) error  { return nil }
+func (s *proxySession) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,46 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package device
+
+import (
+	"fmt"
+
+	"git.sds.co.kr/cherry.git/cherryd/cluster"
+	"git.sds.co.kr/cherry.git/cherryd/openflow"
+	"golang.org/x/net/context"
+)
+
+// Transceiver is implemented by every version-specific transceiver
+// (OF10Transceiver, OF13Transceiver, ...) so a listener can treat them
+// interchangeably once the switch's protocol version is known.
+type Transceiver interface {
+	Run(ctx context.Context)
+}
+
+// NewTransceiver peeks at the peer's HELLO message to figure out which
+// OpenFlow version it speaks, and returns the matching concrete
+// transceiver. This lets a single listener serve mixed OF1.0/1.3 switches
+// instead of committing to one version up front. registry may be nil, in
+// which case the controller runs standalone and always owns every switch
+// it is connected to. pinning may also be nil to disable client-cert
+// pinning.
+func NewTransceiver(stream *openflow.Stream, log Logger, registry cluster.Registry, pinning *DPIDPinning) (Transceiver, error) {
+	version, err := openflow.PeekHelloVersion(stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peer hello: %v", err)
+	}
+
+	switch {
+	case version.Supports(openflow.Ver13):
+		return NewOF13Transceiver(stream, log, registry, pinning), nil
+	case version.Supports(openflow.Ver10):
+		return NewOF10Transceiver(stream, log, registry, pinning), nil
+	default:
+		return nil, fmt.Errorf("unsupported openflow hello version bitmap: %v", version)
+	}
+}
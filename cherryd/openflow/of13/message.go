@@ -0,0 +1,233 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package of13
+
+import (
+	"git.sds.co.kr/cherry.git/cherryd/openflow"
+)
+
+// OpenFlow 1.3 (wire protocol 0x04) message types. Values come straight from
+// the OFP 1.3.x spec, section A.1.
+const (
+	OFPT_HELLO = iota
+	OFPT_ERROR
+	OFPT_ECHO_REQUEST
+	OFPT_ECHO_REPLY
+	OFPT_EXPERIMENTER
+	OFPT_FEATURES_REQUEST
+	OFPT_FEATURES_REPLY
+	OFPT_GET_CONFIG_REQUEST
+	OFPT_GET_CONFIG_REPLY
+	OFPT_SET_CONFIG
+	OFPT_PACKET_IN
+	OFPT_FLOW_REMOVED
+	OFPT_PORT_STATUS
+	OFPT_PACKET_OUT
+	OFPT_FLOW_MOD
+	OFPT_GROUP_MOD
+	OFPT_PORT_MOD
+	OFPT_TABLE_MOD
+	OFPT_MULTIPART_REQUEST
+	OFPT_MULTIPART_REPLY
+	OFPT_BARRIER_REQUEST
+	OFPT_BARRIER_REPLY
+	OFPT_QUEUE_GET_CONFIG_REQUEST
+	OFPT_QUEUE_GET_CONFIG_REPLY
+	OFPT_ROLE_REQUEST
+	OFPT_ROLE_REPLY
+	OFPT_GET_ASYNC_REQUEST
+	OFPT_GET_ASYNC_REPLY
+	OFPT_SET_ASYNC
+	OFPT_METER_MOD
+)
+
+// Multipart (a.k.a. stats) message types. OF13Transceiver.handleMultipartReply
+// parses OFPMP_TABLE and OFPMP_GROUP_DESC replies and logs any other type as
+// unsupported.
+const (
+	OFPMP_DESC = iota
+	OFPMP_FLOW
+	OFPMP_AGGREGATE
+	OFPMP_TABLE
+	OFPMP_PORT_STATS
+	OFPMP_QUEUE
+	OFPMP_GROUP
+	OFPMP_GROUP_DESC
+	OFPMP_GROUP_FEATURES
+)
+
+type FeaturesRequest struct {
+	openflow.Message
+}
+
+func NewFeaturesRequest(xid uint32) *FeaturesRequest {
+	return &FeaturesRequest{
+		Message: openflow.NewMessage(openflow.Ver13, OFPT_FEATURES_REQUEST, xid),
+	}
+}
+
+// FeaturesReply is the OFPT_FEATURES_REPLY body (ofp_switch_features). As in
+// OF1.0, a 1.3 switch advertises its table count directly so the controller
+// does not need to probe table stats just to learn the pipeline depth.
+type FeaturesReply struct {
+	openflow.Message
+	DPID         uint64
+	NBuffers     uint32
+	NTables      uint8
+	AuxiliaryID  uint8
+	Capabilities uint32
+}
+
+func (r *FeaturesReply) UnmarshalBinary(data []byte) error {
+	if len(data) < 24 {
+		return openflow.ErrInvalidPacketLength
+	}
+
+	r.DPID = beUint64(data[0:8])
+	r.NBuffers = beUint32(data[8:12])
+	r.NTables = data[12]
+	r.AuxiliaryID = data[13]
+	r.Capabilities = beUint32(data[16:20])
+
+	return nil
+}
+
+// MultipartRequest is a generic OFPT_MULTIPART_REQUEST used to query
+// per-table flow counters, group descriptions, and group features.
+type MultipartRequest struct {
+	openflow.Message
+	Type  uint16
+	Flags uint16
+	Body  []byte
+}
+
+func NewMultipartRequest(xid uint32, typ uint16, body []byte) *MultipartRequest {
+	return &MultipartRequest{
+		Message: openflow.NewMessage(openflow.Ver13, OFPT_MULTIPART_REQUEST, xid),
+		Type:    typ,
+		Body:    body,
+	}
+}
+
+func NewTableStatsRequest(xid uint32) *MultipartRequest {
+	return NewMultipartRequest(xid, OFPMP_TABLE, nil)
+}
+
+func NewGroupDescStatsRequest(xid uint32) *MultipartRequest {
+	return NewMultipartRequest(xid, OFPMP_GROUP_DESC, nil)
+}
+
+type BarrierRequest struct {
+	openflow.Message
+}
+
+func NewBarrierRequest(xid uint32) *BarrierRequest {
+	return &BarrierRequest{
+		Message: openflow.NewMessage(openflow.Ver13, OFPT_BARRIER_REQUEST, xid),
+	}
+}
+
+// MultipartReply carries the raw body of a stats reply. Callers type-switch
+// on Type to decide how to parse Body.
+type MultipartReply struct {
+	openflow.Message
+	Type  uint16
+	Flags uint16
+	Body  []byte
+}
+
+func (r *MultipartReply) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return openflow.ErrInvalidPacketLength
+	}
+
+	r.Type = beUint16(data[0:2])
+	r.Flags = beUint16(data[2:4])
+	r.Body = data[8:]
+
+	return nil
+}
+
+// TableStats is a single entry of an OFPMP_TABLE reply (ofp_table_stats),
+// which is a fixed 24-byte struct: table_id(1) + pad(3) + active_count(4) +
+// lookup_count(8) + matched_count(8).
+type TableStats struct {
+	TableID      uint8
+	ActiveCount  uint32
+	LookupCount  uint64
+	MatchedCount uint64
+}
+
+const tableStatsLen = 24
+
+// ParseTableStats decodes the repeated ofp_table_stats entries of an
+// OFPMP_TABLE reply body.
+func ParseTableStats(body []byte) ([]TableStats, error) {
+	if len(body)%tableStatsLen != 0 {
+		return nil, openflow.ErrInvalidPacketLength
+	}
+
+	stats := make([]TableStats, 0, len(body)/tableStatsLen)
+	for i := 0; i < len(body); i += tableStatsLen {
+		entry := body[i : i+tableStatsLen]
+		stats = append(stats, TableStats{
+			TableID:      entry[0],
+			ActiveCount:  beUint32(entry[4:8]),
+			LookupCount:  beUint64(entry[8:16]),
+			MatchedCount: beUint64(entry[16:24]),
+		})
+	}
+
+	return stats, nil
+}
+
+// GroupDesc is a single entry of an OFPMP_GROUP_DESC reply (ofp_group_desc).
+// Bucket details aren't captured: the controller only needs to know which
+// group IDs and types exist on the switch to validate its own GROUP_MOD
+// requests against, not replay the switch's own bucket state back to it.
+type GroupDesc struct {
+	Type    uint8
+	GroupID uint32
+}
+
+// ofpGroupDescHeaderLen is length(2) + type(1) + pad(1) + group_id(4).
+const ofpGroupDescHeaderLen = 8
+
+// ParseGroupDescStats decodes the repeated, variable-length ofp_group_desc
+// entries of an OFPMP_GROUP_DESC reply body.
+func ParseGroupDescStats(body []byte) ([]GroupDesc, error) {
+	var stats []GroupDesc
+
+	for i := 0; i+ofpGroupDescHeaderLen <= len(body); {
+		length := int(beUint16(body[i : i+2]))
+		if length < ofpGroupDescHeaderLen || i+length > len(body) {
+			return nil, openflow.ErrInvalidPacketLength
+		}
+
+		stats = append(stats, GroupDesc{
+			Type:    body[i+2],
+			GroupID: beUint32(body[i+4 : i+8]),
+		})
+
+		i += length
+	}
+
+	return stats, nil
+}
+
+func beUint16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func beUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func beUint64(b []byte) uint64 {
+	return uint64(beUint32(b[0:4]))<<32 | uint64(beUint32(b[4:8]))
+}
@@ -0,0 +1,73 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package of13
+
+import (
+	"testing"
+)
+
+func TestParseTableStats(t *testing.T) {
+	// Two 24-byte ofp_table_stats entries: table_id(1) + pad(3) +
+	// active_count(4) + lookup_count(8) + matched_count(8).
+	body := []byte{
+		0x00, 0, 0, 0, 0x00, 0x00, 0x00, 0x01, // table 0, active_count=1
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0x01, 0, 0, 0, 0x00, 0x00, 0x00, 0x02, // table 1, active_count=2
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+
+	stats, err := ParseTableStats(body)
+	if err != nil {
+		t.Fatalf("ParseTableStats returned an error: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("len(stats) = %v, want 2", len(stats))
+	}
+	if stats[0].TableID != 0 || stats[0].ActiveCount != 1 {
+		t.Errorf("stats[0] = %+v, want TableID=0 ActiveCount=1", stats[0])
+	}
+	if stats[1].TableID != 1 || stats[1].ActiveCount != 2 {
+		t.Errorf("stats[1] = %+v, want TableID=1 ActiveCount=2", stats[1])
+	}
+}
+
+func TestParseTableStatsInvalidLength(t *testing.T) {
+	if _, err := ParseTableStats(make([]byte, tableStatsLen-1)); err == nil {
+		t.Error("expected an error for a body that isn't a multiple of the entry length")
+	}
+}
+
+func TestParseGroupDescStats(t *testing.T) {
+	// One 8-byte ofp_group_desc entry (length=8, no buckets): length(2) +
+	// type(1) + pad(1) + group_id(4).
+	body := []byte{
+		0x00, 0x08, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2a,
+	}
+
+	stats, err := ParseGroupDescStats(body)
+	if err != nil {
+		t.Fatalf("ParseGroupDescStats returned an error: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("len(stats) = %v, want 1", len(stats))
+	}
+	if stats[0].Type != 1 || stats[0].GroupID != 0x2a {
+		t.Errorf("stats[0] = %+v, want Type=1 GroupID=42", stats[0])
+	}
+}
+
+func TestParseGroupDescStatsInvalidLength(t *testing.T) {
+	// Claims a length longer than the remaining body.
+	body := []byte{0x00, 0xff, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2a}
+
+	if _, err := ParseGroupDescStats(body); err == nil {
+		t.Error("expected an error for an entry whose length overruns the body")
+	}
+}
@@ -0,0 +1,30 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package openflow
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// PeerCertificate returns the client certificate the peer presented during
+// the TLS handshake, or nil if the stream isn't running over TLS or the
+// peer presented no certificate.
+func (s *Stream) PeerCertificate() *x509.Certificate {
+	conn, ok := s.Conn().(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return state.PeerCertificates[0]
+}
@@ -0,0 +1,130 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package openflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Ver13 is the wire protocol version number for OpenFlow 1.3.x.
+const Ver13 = 0x04
+
+// ErrInvalidPacketLength is returned while decoding a message or stats body
+// whose length doesn't fit its structure: shorter than its fixed header, not
+// a whole number of fixed-size entries, or overrunning the data it came in.
+var ErrInvalidPacketLength = errors.New("invalid packet length")
+
+// message is a minimal concrete Message used by NewMessage below.
+type message struct {
+	version uint8
+	msgType uint8
+	xid     uint32
+}
+
+func (m *message) Header() Header {
+	return Header{Version: m.version, Type: m.msgType, XID: m.xid}
+}
+
+// NewMessage builds a bare Message carrying only a header, for use as the
+// embedded base of higher level request/reply types (see of13.FeaturesRequest).
+func NewMessage(version uint8, msgType uint8, xid uint32) Message {
+	return &message{version: version, msgType: msgType, xid: xid}
+}
+
+// HelloElemVersionBitmap is the decoded OFPHET_VERSIONBITMAP element of a
+// HELLO message: the set of wire protocol versions the peer is willing to
+// speak, used to pick a concrete Transceiver before committing to one.
+type HelloElemVersionBitmap struct {
+	bitmap uint32
+}
+
+func (v HelloElemVersionBitmap) Supports(version uint8) bool {
+	return v.bitmap&(1<<uint(version)) != 0
+}
+
+// ofptHello is the OFPT_HELLO message type, which has the same numeric
+// value (0) in every OpenFlow version, and is always the first message
+// either side of the connection sends.
+const ofptHello = 0
+
+// helloElemVersionBitmapType is the OFPHET_VERSIONBITMAP element type
+// carried in a HELLO body (OF1.3.1+, section 7.5.1).
+const helloElemVersionBitmapType = 1
+
+// PeekHelloVersion reads the peer's HELLO message straight off the stream's
+// connection (bypassing the higher-level Message decoding, since the
+// version isn't known yet) and returns its advertised version bitmap. The
+// HELLO is always the very first message on a fresh connection, so this
+// consumes it; callers construct the version-specific Transceiver
+// afterwards and never need to see that HELLO again. If the peer is too
+// old to send a version bitmap element, the bitmap contains only the
+// version carried in the HELLO header itself.
+func PeekHelloVersion(stream *Stream) (HelloElemVersionBitmap, error) {
+	conn := stream.Conn()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return HelloElemVersionBitmap{}, err
+	}
+
+	version := header[0]
+	msgType := header[1]
+	length := binary.BigEndian.Uint16(header[2:4])
+	if msgType != ofptHello {
+		return HelloElemVersionBitmap{}, fmt.Errorf("expected HELLO as the first message, got type %v", msgType)
+	}
+	if length < 8 {
+		return HelloElemVersionBitmap{}, ErrInvalidPacketLength
+	}
+
+	bitmap := uint32(1) << uint(version)
+
+	if length > 8 {
+		body := make([]byte, int(length)-8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return HelloElemVersionBitmap{}, err
+		}
+		if b, ok := parseHelloVersionBitmap(body); ok {
+			bitmap = b
+		}
+	}
+
+	return HelloElemVersionBitmap{bitmap: bitmap}, nil
+}
+
+// parseHelloVersionBitmap scans a HELLO body's list of elements for an
+// OFPHET_VERSIONBITMAP element and ORs together its bitmap words. Elements
+// are padded to 8-byte boundaries (OF1.3.1+, section 7.5.1).
+func parseHelloVersionBitmap(body []byte) (uint32, bool) {
+	for i := 0; i+4 <= len(body); {
+		elemType := binary.BigEndian.Uint16(body[i : i+2])
+		elemLength := binary.BigEndian.Uint16(body[i+2 : i+4])
+		if elemLength < 4 || i+int(elemLength) > len(body) {
+			return 0, false
+		}
+
+		if elemType == helloElemVersionBitmapType {
+			var bitmap uint32
+			for j := i + 4; j+4 <= i+int(elemLength); j += 4 {
+				bitmap |= binary.BigEndian.Uint32(body[j : j+4])
+			}
+			return bitmap, true
+		}
+
+		advance := int(elemLength)
+		if pad := advance % 8; pad != 0 {
+			advance += 8 - pad
+		}
+		i += advance
+	}
+
+	return 0, false
+}
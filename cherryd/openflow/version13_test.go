@@ -0,0 +1,92 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package openflow
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// helloElem encodes one OFPHET_* element as it appears in a HELLO body:
+// type(2) + length(2) + payload, padded to an 8-byte boundary.
+func helloElem(elemType uint16, payload []byte) []byte {
+	length := 4 + len(payload)
+	elem := make([]byte, length)
+	binary.BigEndian.PutUint16(elem[0:2], elemType)
+	binary.BigEndian.PutUint16(elem[2:4], uint16(length))
+	copy(elem[4:], payload)
+
+	if pad := length % 8; pad != 0 {
+		elem = append(elem, make([]byte, 8-pad)...)
+	}
+	return elem
+}
+
+func TestParseHelloVersionBitmapSingleWord(t *testing.T) {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, 1<<Ver13)
+	body := helloElem(helloElemVersionBitmapType, payload)
+
+	bitmap, ok := parseHelloVersionBitmap(body)
+	if !ok {
+		t.Fatal("expected a version bitmap element to be found")
+	}
+	if bitmap != 1<<Ver13 {
+		t.Errorf("bitmap = %#x, want %#x", bitmap, uint32(1<<Ver13))
+	}
+}
+
+func TestParseHelloVersionBitmapMultipleWords(t *testing.T) {
+	// Two 4-byte words OR'd together, as a peer advertising versions
+	// spread across bit 0x04 and bit 0x25 (beyond the first word) would.
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint32(payload[0:4], 1<<Ver13)
+	binary.BigEndian.PutUint32(payload[4:8], 1<<5)
+	body := helloElem(helloElemVersionBitmapType, payload)
+
+	bitmap, ok := parseHelloVersionBitmap(body)
+	if !ok {
+		t.Fatal("expected a version bitmap element to be found")
+	}
+	if want := uint32(1<<Ver13) | uint32(1<<5); bitmap != want {
+		t.Errorf("bitmap = %#x, want %#x", bitmap, want)
+	}
+}
+
+func TestParseHelloVersionBitmapSkipsUnknownElements(t *testing.T) {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, 1<<Ver13)
+
+	var body []byte
+	body = append(body, helloElem(0xff, []byte{1, 2, 3, 4})...)
+	body = append(body, helloElem(helloElemVersionBitmapType, payload)...)
+
+	bitmap, ok := parseHelloVersionBitmap(body)
+	if !ok {
+		t.Fatal("expected a version bitmap element to be found")
+	}
+	if bitmap != 1<<Ver13 {
+		t.Errorf("bitmap = %#x, want %#x", bitmap, uint32(1<<Ver13))
+	}
+}
+
+func TestParseHelloVersionBitmapNotPresent(t *testing.T) {
+	body := helloElem(0xff, []byte{1, 2, 3, 4})
+
+	if _, ok := parseHelloVersionBitmap(body); ok {
+		t.Error("expected no version bitmap element to be found")
+	}
+}
+
+func TestParseHelloVersionBitmapTruncatedElement(t *testing.T) {
+	body := []byte{0x00, 0x01, 0x00, 0xff} // claims a length longer than the body
+
+	if _, ok := parseHelloVersionBitmap(body); ok {
+		t.Error("expected a truncated element to be rejected")
+	}
+}
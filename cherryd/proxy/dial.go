@@ -0,0 +1,82 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// UpstreamTLSConfig points at the PEM files used to authenticate the
+// north-side connection to an upstream cherry controller: this proxy's own
+// client certificate, and the CA used to verify the controller's server
+// certificate. Without this, any TCP client that can reach a controller's
+// proxy-facing port could inject framed OpenFlow traffic as if it were a
+// trusted proxy.
+type UpstreamTLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// newUpstreamTLSConfig builds the *tls.Config used to dial an upstream
+// controller: mutual auth, presenting config.CertFile/KeyFile and verifying
+// the controller's certificate against config.CAFile.
+func newUpstreamTLSConfig(config UpstreamTLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS cert/key: %v", err)
+	}
+
+	caPEM, err := ioutil.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %v", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("failed to parse CA file: %v", config.CAFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// DialUpstream tries each address in upstreams in order, returning the
+// first one it manages to establish an authenticated TLS connection to.
+// This is the failover used on startup and whenever the current upstream
+// connection is lost: a single controller restarting or a network blip
+// doesn't require restarting cherry-proxy or losing its south-side
+// sessions for longer than the next dial takes.
+func DialUpstream(upstreams []string, config UpstreamTLSConfig) (net.Conn, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("proxy: no upstream controllers configured")
+	}
+
+	tlsConfig, err := newUpstreamTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range upstreams {
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to connect to upstream controller %v: %v", addr, err)
+			continue
+		}
+		return conn, nil
+	}
+
+	return nil, lastErr
+}
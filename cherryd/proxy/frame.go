@@ -0,0 +1,83 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+// Package proxy implements the framing used between a cherry-proxy (which
+// terminates raw OpenFlow connections from switches) and the upstream cherry
+// controllers it fans those sessions in to. The south side of a proxy
+// speaks unmodified OpenFlow; the north side multiplexes every south
+// session over one persistent, authenticated connection using the framing
+// in this file.
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// frameHeader is fixed-size and precedes every OpenFlow message on the
+// north-side connection:
+//
+//	8 bytes  session ID   (identifies one south-side TCP connection)
+//	8 bytes  DPID         (0 until the switch's FEATURES_REPLY is observed)
+//	4 bytes  payload length
+const frameHeaderLen = 8 + 8 + 4
+
+// maxFrameLen bounds a single OpenFlow message so a malformed length field
+// can't make a peer try to allocate an unbounded buffer.
+const maxFrameLen = 16 * 1024 * 1024
+
+// Frame is one OpenFlow message tagged with the south-side session it
+// belongs to, and the DPID of the switch that session serves (once known).
+type Frame struct {
+	SessionID uint64
+	DPID      uint64
+	Payload   []byte
+}
+
+// WriteFrame writes f to w in the north-side wire format.
+func WriteFrame(w io.Writer, f Frame) error {
+	if len(f.Payload) > maxFrameLen {
+		return fmt.Errorf("proxy: frame payload too large: %v bytes", len(f.Payload))
+	}
+
+	header := make([]byte, frameHeaderLen)
+	binary.BigEndian.PutUint64(header[0:8], f.SessionID)
+	binary.BigEndian.PutUint64(header[8:16], f.DPID)
+	binary.BigEndian.PutUint32(header[16:20], uint32(len(f.Payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(f.Payload)
+	return err
+}
+
+// ReadFrame reads the next frame from r, blocking until a full frame has
+// arrived.
+func ReadFrame(r io.Reader) (Frame, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Frame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[16:20])
+	if length > maxFrameLen {
+		return Frame{}, fmt.Errorf("proxy: frame payload too large: %v bytes", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return Frame{}, err
+	}
+
+	return Frame{
+		SessionID: binary.BigEndian.Uint64(header[0:8]),
+		DPID:      binary.BigEndian.Uint64(header[8:16]),
+		Payload:   payload,
+	}, nil
+}
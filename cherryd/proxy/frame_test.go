@@ -0,0 +1,71 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteReadFrameRoundTrip(t *testing.T) {
+	want := Frame{SessionID: 7, DPID: 42, Payload: []byte{0x01, 0x02, 0x03}}
+
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, want); err != nil {
+		t.Fatalf("WriteFrame returned an error: %v", err)
+	}
+
+	got, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame returned an error: %v", err)
+	}
+	if got.SessionID != want.SessionID || got.DPID != want.DPID || !bytes.Equal(got.Payload, want.Payload) {
+		t.Errorf("ReadFrame = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteReadFrameEmptyPayload(t *testing.T) {
+	want := Frame{SessionID: 1, DPID: 0, Payload: nil}
+
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, want); err != nil {
+		t.Fatalf("WriteFrame returned an error: %v", err)
+	}
+
+	got, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame returned an error: %v", err)
+	}
+	if len(got.Payload) != 0 {
+		t.Errorf("Payload = %v, want empty", got.Payload)
+	}
+}
+
+func TestWriteFrameRejectsOversizedPayload(t *testing.T) {
+	f := Frame{Payload: make([]byte, maxFrameLen+1)}
+
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, f); err == nil {
+		t.Error("expected an error for a payload larger than maxFrameLen")
+	}
+}
+
+func TestReadFrameRejectsOversizedLengthField(t *testing.T) {
+	header := make([]byte, frameHeaderLen)
+	header[16] = 0x7f // length field's top byte, well past maxFrameLen
+
+	if _, err := ReadFrame(bytes.NewReader(header)); err == nil {
+		t.Error("expected an error for a frame header claiming an oversized payload")
+	}
+}
+
+func TestReadFrameRejectsTruncatedHeader(t *testing.T) {
+	if _, err := ReadFrame(bytes.NewReader(make([]byte, frameHeaderLen-1))); err == nil {
+		t.Error("expected an error for a truncated frame header")
+	}
+}
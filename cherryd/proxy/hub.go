@@ -0,0 +1,155 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service Co., Ltd.,
+ * Kitae Kim <superkkt@sds.co.kr>
+ */
+
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ofpHeaderLen is the size of the header shared by every OpenFlow version
+// this proxy relays (version, type, length, xid) - section A.1 of both the
+// 1.0 and 1.3 specs. The proxy only needs to find message boundaries and
+// peek at FEATURES_REPLY, so it never has to parse a full message.
+const ofpHeaderLen = 8
+
+// maxOFPMessageLen bounds a single south-side OpenFlow message so a
+// malformed or hostile switch can't make readOFPMessage try to allocate an
+// unbounded buffer; it matches maxFrameLen, since a message this proxy
+// can't forward north in one frame can't be relayed anyway.
+const maxOFPMessageLen = maxFrameLen
+
+const ofptFeaturesReply10 = 6 // same numeric value in OF1.0 and OF1.3
+
+// Hub terminates south-side OpenFlow TCP/TLS sessions from switches and
+// multiplexes them, framed, over one persistent connection to an upstream
+// controller. It reuses the OpenFlow wire format verbatim on the south
+// side; only the north side is wrapped in Frame. The north-side connection
+// can be swapped out with SetNorth while south sessions stay up, so a
+// caller can fail over between a pool of upstream controllers without
+// dropping every switch connection.
+type Hub struct {
+	north   net.Conn
+	writeMu sync.Mutex
+
+	nextSessionID uint64
+
+	mu       sync.Mutex
+	sessions map[uint64]net.Conn
+}
+
+// NewHub returns a Hub with no north-side connection yet; call SetNorth
+// once one is established.
+func NewHub() *Hub {
+	return &Hub{
+		sessions: make(map[uint64]net.Conn),
+	}
+}
+
+// SetNorth replaces the upstream connection south-side sessions write to.
+// Callers swap this in after failing over to a different upstream
+// controller in the pool.
+func (h *Hub) SetNorth(north net.Conn) {
+	h.writeMu.Lock()
+	h.north = north
+	h.writeMu.Unlock()
+}
+
+// Serve relays south, the just-accepted connection from a switch, to the
+// Hub's current upstream controller, and blocks until south is closed in
+// either direction.
+func (h *Hub) Serve(south net.Conn) error {
+	sessionID := atomic.AddUint64(&h.nextSessionID, 1)
+
+	h.mu.Lock()
+	h.sessions[sessionID] = south
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, sessionID)
+		h.mu.Unlock()
+	}()
+
+	var dpid uint64
+	for {
+		msg, msgType, err := readOFPMessage(south)
+		if err != nil {
+			return err
+		}
+		if msgType == ofptFeaturesReply10 && len(msg) >= ofpHeaderLen+8 {
+			dpid = binary.BigEndian.Uint64(msg[ofpHeaderLen : ofpHeaderLen+8])
+		}
+
+		h.writeMu.Lock()
+		north := h.north
+		h.writeMu.Unlock()
+		if north == nil {
+			return fmt.Errorf("proxy: no upstream connection available")
+		}
+
+		if err := WriteFrame(north, Frame{SessionID: sessionID, DPID: dpid, Payload: msg}); err != nil {
+			return err
+		}
+	}
+}
+
+// Dispatch reads frames arriving from north forever, and writes each one's
+// payload back to the matching south-side switch session. SendMessage on
+// the controller side routes through the same north connection, tagged
+// with the session it must come back out on. Callers should SetNorth(north)
+// before calling Dispatch, and again (with the newly dialed connection)
+// once Dispatch returns after a failover.
+func (h *Hub) Dispatch(north net.Conn) error {
+	for {
+		frame, err := ReadFrame(north)
+		if err != nil {
+			return err
+		}
+
+		h.mu.Lock()
+		south, ok := h.sessions[frame.SessionID]
+		h.mu.Unlock()
+		if !ok {
+			// The switch already disconnected; drop the stale reply.
+			continue
+		}
+		if _, err := south.Write(frame.Payload); err != nil {
+			return err
+		}
+	}
+}
+
+// readOFPMessage reads exactly one OpenFlow message (header + body) off r
+// and returns its raw bytes along with its message type.
+func readOFPMessage(r io.Reader) ([]byte, uint8, error) {
+	header := make([]byte, ofpHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+
+	length := binary.BigEndian.Uint16(header[2:4])
+	if length < ofpHeaderLen {
+		return nil, 0, fmt.Errorf("proxy: openflow message length too short: %v bytes", length)
+	}
+	if length > maxOFPMessageLen {
+		return nil, 0, fmt.Errorf("proxy: openflow message too large: %v bytes", length)
+	}
+
+	body := make([]byte, int(length)-ofpHeaderLen)
+	if len(body) > 0 {
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return append(header, body...), header[1], nil
+}
@@ -0,0 +1,93 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package cluster lets several Cherry controllers jointly manage a fabric.
+// Each switch DPID is arbitrated by a lease in a shared registry (etcd or
+// Consul); whichever node holds the lease is the active controller for that
+// switch, and every other node that also holds the TCP connection is a warm
+// standby that takes over on lease loss.
+package cluster
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Lease represents ownership of a single key in the registry for a bounded
+// period of time. The holder must renew it (handled internally by the
+// Registry implementation), or another node may acquire it.
+type Lease interface {
+	// Key is the resource this lease arbitrates, e.g. "cherry/switch/<dpid>"
+	// or "cherry/singleton/proxyarp-broadcaster".
+	Key() string
+	// Released is closed the moment this node stops being the leaseholder,
+	// whether from a graceful Release() or because the registry revoked it
+	// (missed renewals, network partition, etc).
+	Released() <-chan struct{}
+	// Release gives the lease up immediately, letting a standby promote
+	// itself without waiting for the TTL to expire.
+	Release() error
+}
+
+// Registry is the shared coordination backend (etcd, Consul, ...) that
+// arbitrates leases across controller nodes.
+type Registry interface {
+	// Acquire blocks until this node holds the lease for key, or ctx is
+	// cancelled. Only one node at a time can hold a given key.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+	// Owner returns the node ID currently holding key, if any.
+	Owner(key string) (node string, ok bool, err error)
+}
+
+// SwitchKey returns the registry key used to arbitrate ownership of the
+// switch identified by dpid.
+func SwitchKey(dpid uint64) string {
+	return "cherry/switch/" + strconv.FormatUint(dpid, 10)
+}
+
+// SingletonKey returns the registry key used to elect a single, cluster-wide
+// owner for a named singleton task (e.g. the ProxyARP broadcaster).
+func SingletonKey(name string) string {
+	return "cherry/singleton/" + name
+}
+
+// Finder looks up which cluster node currently owns a switch, so a node
+// that is only a standby for that DPID can forward a PACKET_OUT to the
+// node that actually holds the connection's active role.
+type Finder interface {
+	Owner(dpid uint64) (node string, ok bool, err error)
+}
+
+type registryFinder struct {
+	registry Registry
+}
+
+// NewFinder adapts a Registry into a Finder scoped to switch ownership
+// lookups.
+func NewFinder(registry Registry) Finder {
+	return &registryFinder{registry: registry}
+}
+
+func (f *registryFinder) Owner(dpid uint64) (string, bool, error) {
+	return f.registry.Owner(SwitchKey(dpid))
+}
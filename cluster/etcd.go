@@ -0,0 +1,131 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+	"golang.org/x/net/context"
+)
+
+// EtcdRegistry backs Registry with an etcd cluster, using etcd's built-in
+// lease + election primitives so we don't have to hand-roll fencing tokens.
+// It's the only concrete Registry in the tree, so it backs both the device
+// package's per-switch leader election and the northbound apps' singleton
+// broadcaster election.
+type EtcdRegistry struct {
+	client *clientv3.Client
+	node   string
+}
+
+// NewEtcdRegistry connects to the given etcd endpoints. node is this
+// controller's own identity (e.g. "host:port") and is what Owner() returns
+// for keys this node currently holds.
+func NewEtcdRegistry(endpoints []string, node string) (*EtcdRegistry, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EtcdRegistry{client: client, node: node}, nil
+}
+
+type etcdLease struct {
+	key      string
+	session  *concurrency.Session
+	election *concurrency.Election
+	released chan struct{}
+	// closeOnce guards released: both Release and the session-watcher
+	// goroutine started in Acquire race to close it (a Release that
+	// resigns the election also closes the session, which wakes the
+	// watcher), and closing an already-closed channel panics.
+	closeOnce sync.Once
+}
+
+func (l *etcdLease) Key() string {
+	return l.key
+}
+
+func (l *etcdLease) Released() <-chan struct{} {
+	return l.released
+}
+
+func (l *etcdLease) markReleased() {
+	l.closeOnce.Do(func() {
+		close(l.released)
+	})
+}
+
+func (l *etcdLease) Release() error {
+	defer l.session.Close()
+	defer l.markReleased()
+	return l.election.Resign(context.Background())
+}
+
+func (r *EtcdRegistry) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	session, err := concurrency.NewSession(r.client, concurrency.WithTTL(int(ttl.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+
+	election := concurrency.NewElection(session, key)
+	if err := election.Campaign(ctx, r.node); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	lease := &etcdLease{
+		key:      key,
+		session:  session,
+		election: election,
+		released: make(chan struct{}),
+	}
+	// The session's Done channel fires when etcd revokes our underlying
+	// lease (missed keepalives, partition, ...), which means we silently
+	// stopped being the leader.
+	go func() {
+		<-session.Done()
+		lease.markReleased()
+	}()
+
+	return lease, nil
+}
+
+func (r *EtcdRegistry) Owner(key string) (string, bool, error) {
+	// Campaign stores each candidate under key+"/"+<lease ID>; the leader is
+	// the candidate whose entry was created first.
+	resp, err := r.client.Get(context.Background(), key+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", false, err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", false, nil
+	}
+
+	return string(resp.Kvs[0].Value), true, nil
+}
@@ -0,0 +1,134 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package cluster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Forwarder hands a PACKET_OUT payload to whichever cluster node owns dpid,
+// so a node that only holds the switch's TCP connection as a standby can
+// still answer on behalf of the node that's actually allowed to act (see
+// Finder). A node never needs to forward to itself: callers should check
+// Finder.Owner against their own node ID first.
+type Forwarder interface {
+	Forward(node string, dpid uint64, port uint32, packet []byte) error
+}
+
+// dialTimeout bounds how long Forward waits to reach a peer node before
+// giving up; a stuck forward shouldn't stall the caller's packet-in path.
+const dialTimeout = 2 * time.Second
+
+// NetForwarder forwards packets over a plain length-prefixed TCP frame to
+// the peer's forwarding listener. node addresses are the same "host:port"
+// identity a Registry hands back from Owner.
+type NetForwarder struct{}
+
+// NewNetForwarder returns a Forwarder that dials peer nodes directly. There
+// is no connection pooling: cross-node forwarding is the exception (a
+// standby only forwards while its own node hasn't yet won the switch's
+// lease), not the steady-state path, so a short-lived dial per forward
+// keeps this simple.
+func NewNetForwarder() *NetForwarder {
+	return &NetForwarder{}
+}
+
+// frame is header(dpid:8 + port:4 + length:4) followed by the raw packet.
+const forwardHeaderLen = 8 + 4 + 4
+
+func (f *NetForwarder) Forward(node string, dpid uint64, port uint32, packet []byte) error {
+	conn, err := net.DialTimeout("tcp", node, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to dial forwarding peer %v: %v", node, err)
+	}
+	defer conn.Close()
+
+	header := make([]byte, forwardHeaderLen)
+	binary.BigEndian.PutUint64(header[0:8], dpid)
+	binary.BigEndian.PutUint32(header[8:12], port)
+	binary.BigEndian.PutUint32(header[12:16], uint32(len(packet)))
+
+	if _, err := conn.Write(header); err != nil {
+		return fmt.Errorf("failed to write forward header to %v: %v", node, err)
+	}
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to write forwarded packet to %v: %v", node, err)
+	}
+
+	return nil
+}
+
+// maxForwardedPacketLen bounds the length a ListenAndServeForwarding peer
+// will accept, so a malformed or hostile peer can't make it allocate an
+// unbounded buffer.
+const maxForwardedPacketLen = 1 << 16
+
+// Deliver is called with the decoded contents of a single forwarded frame:
+// the DPID of the switch to act on behalf of, the switch port to send the
+// packet out of, and the raw packet itself.
+type Deliver func(dpid uint64, port uint32, packet []byte) error
+
+// ListenAndServeForwarding accepts forwarded frames on addr and hands each
+// one to deliver, until the listener is closed. It's the receiving half of
+// NetForwarder, run by every node that wants to be forwardable to.
+func ListenAndServeForwarding(addr string, deliver Deliver) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go serveForwardConn(conn, deliver)
+	}
+}
+
+func serveForwardConn(conn net.Conn, deliver Deliver) {
+	defer conn.Close()
+
+	header := make([]byte, forwardHeaderLen)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+
+	dpid := binary.BigEndian.Uint64(header[0:8])
+	port := binary.BigEndian.Uint32(header[8:12])
+	length := binary.BigEndian.Uint32(header[12:16])
+	if length > maxForwardedPacketLen {
+		return
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(conn, packet); err != nil {
+		return
+	}
+
+	deliver(dpid, port, packet)
+}
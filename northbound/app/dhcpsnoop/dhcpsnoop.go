@@ -0,0 +1,323 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package dhcpsnoop watches DHCPv4 traffic to auto-populate ProxyARP's host
+// database, and enforces that only trusted ports may act as a DHCP server -
+// the same anti-spoofing boundary ProxyARP's design already assumes.
+package dhcpsnoop
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/go-logging"
+)
+
+var (
+	logger = logging.MustGetLogger("dhcpsnoop")
+)
+
+const (
+	dhcpServerPort = 67
+	dhcpClientPort = 68
+
+	dhcpOpReply = 2
+
+	dhcpOptionPad         = 0
+	dhcpOptionMessageType = 53
+	dhcpMessageTypeOffer  = 2
+	dhcpMessageTypeAck    = 5
+	dhcpOptionLeaseTime   = 51
+	dhcpOptionEnd         = 255
+
+	defaultLeaseTime = 1 * time.Hour
+)
+
+type database interface {
+	PutHost(ip net.IP, mac net.HardwareAddr, ingress uint32, expiry time.Time) error
+	ExpireHost(ip net.IP) error
+}
+
+// DHCPSnoop learns (IP, MAC, lease-expiry, ingress-port) bindings by
+// watching DHCP OFFER/ACK exchanges, and drops DHCP server traffic arriving
+// on any port not in its trusted allow-list.
+type DHCPSnoop struct {
+	app.BaseProcessor
+	db database
+
+	// trustedPorts lists the switch ports a DHCP server may legitimately
+	// answer from. A flow is installed on every other ingress port to
+	// drop DHCP server traffic before it ever reaches this app, so a
+	// rogue DHCP server on an access port can't race a real one.
+	trustedPorts map[uint32]bool
+
+	mu    sync.Mutex
+	armed map[uint64]bool // dpid -> anti-spoof flows installed
+
+	leaseMu sync.Mutex
+	leases  map[string]time.Time // IP string -> expiry, swept by expireLoop
+}
+
+// New returns a DHCPSnoop app. trustedPorts are switch port numbers that are
+// allowed to carry DHCP server traffic (src port 67); every other port gets
+// a drop flow installed for that traffic the first time the app sees the
+// device come up.
+func New(db database, trustedPorts []uint32) *DHCPSnoop {
+	trusted := make(map[uint32]bool)
+	for _, p := range trustedPorts {
+		trusted[p] = true
+	}
+
+	r := &DHCPSnoop{
+		db:           db,
+		trustedPorts: trusted,
+		armed:        make(map[uint64]bool),
+		leases:       make(map[string]time.Time),
+	}
+	go r.expireLoop()
+
+	return r
+}
+
+// expireLoop periodically sweeps tracked leases and calls ExpireHost on any
+// that have run out, so the ProxyARP broadcaster stops announcing bindings
+// that are no longer valid.
+func (r *DHCPSnoop) expireLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		r.leaseMu.Lock()
+		var expired []string
+		for ip, expiry := range r.leases {
+			if now.After(expiry) {
+				expired = append(expired, ip)
+			}
+		}
+		for _, ip := range expired {
+			delete(r.leases, ip)
+		}
+		r.leaseMu.Unlock()
+
+		for _, ip := range expired {
+			logger.Debugf("DHCP lease expired for %v", ip)
+			if err := r.db.ExpireHost(net.ParseIP(ip)); err != nil {
+				logger.Errorf("failed to expire host %v: %v", ip, err)
+			}
+		}
+	}
+}
+
+func (r *DHCPSnoop) Init() error {
+	return nil
+}
+
+func (r *DHCPSnoop) Name() string {
+	return "DHCPSnoop"
+}
+
+func (r *DHCPSnoop) OnDeviceUp(finder network.Finder, device *network.Device) error {
+	if err := r.installAntiSpoofFlows(device); err != nil {
+		logger.Errorf("failed to install anti-spoofing flows on dpid=%v: %v", device.ID(), err)
+	}
+
+	return r.BaseProcessor.OnDeviceUp(finder, device)
+}
+
+// installAntiSpoofFlows drops UDP src-port-67 traffic arriving on any port
+// that isn't in the trusted allow-list, so a host can never pose as a DHCP
+// server. It only needs to run once per device.
+func (r *DHCPSnoop) installAntiSpoofFlows(device *network.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.armed[device.ID()] {
+		return nil
+	}
+
+	for _, port := range device.Ports() {
+		if r.trustedPorts[port.Number()] {
+			continue
+		}
+		if err := dropDHCPServerTraffic(device, port.Number()); err != nil {
+			return err
+		}
+	}
+
+	r.armed[device.ID()] = true
+	return nil
+}
+
+func dropDHCPServerTraffic(device *network.Device, ingress uint32) error {
+	f := device.Factory()
+
+	match, err := f.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetInPort(ingress)
+	match.SetUDPSrcPort(dhcpServerPort)
+
+	flow, err := f.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		return err
+	}
+	flow.SetMatch(match)
+	flow.SetPriority(openflow.HighestPriority)
+	// No actions means drop.
+
+	return device.SendMessage(flow)
+}
+
+func (r *DHCPSnoop) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+	// IPv4?
+	if eth.Type != 0x0800 {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	ip4 := new(protocol.IPv4)
+	if err := ip4.UnmarshalBinary(eth.Payload); err != nil {
+		return err
+	}
+	// UDP?
+	if ip4.Protocol != 17 {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	udp := new(protocol.UDP)
+	if err := udp.UnmarshalBinary(ip4.Payload); err != nil {
+		return err
+	}
+	// DHCP server -> client traffic only; client -> server (DISCOVER/
+	// REQUEST) carries no binding we can trust yet.
+	if udp.SrcPort != dhcpServerPort || udp.DstPort != dhcpClientPort {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	msg, err := parseDHCP4(udp.Payload)
+	if err != nil {
+		logger.Debugf("failed to parse DHCP payload: %v", err)
+		return nil
+	}
+	if msg.Op != dhcpOpReply {
+		return nil
+	}
+
+	switch msg.MessageType {
+	case dhcpMessageTypeOffer, dhcpMessageTypeAck:
+		lease := msg.LeaseTime
+		if lease == 0 {
+			lease = defaultLeaseTime
+		}
+		expiry := time.Now().Add(lease)
+
+		logger.Debugf("learned DHCP binding: IP=%v, MAC=%v, ingress=%v, expiry=%v", msg.YourIP, msg.ClientMAC, ingress.ID(), expiry)
+		if err := r.db.PutHost(msg.YourIP, msg.ClientMAC, ingress.Number(), expiry); err != nil {
+			return errors.Wrap(err, "failed to store DHCP binding")
+		}
+
+		r.leaseMu.Lock()
+		r.leases[msg.YourIP.String()] = expiry
+		r.leaseMu.Unlock()
+	}
+
+	return nil
+}
+
+// dhcp4Message holds the handful of BOOTP/DHCP fields this app cares about.
+type dhcp4Message struct {
+	Op          uint8
+	YourIP      net.IP
+	ClientMAC   net.HardwareAddr
+	MessageType uint8
+	LeaseTime   time.Duration
+}
+
+// parseDHCP4 decodes just enough of a BOOTP packet (RFC 951) plus its DHCP
+// options (RFC 2132) to learn a lease binding; it does not validate the
+// packet beyond what's needed to safely index into it.
+func parseDHCP4(data []byte) (*dhcp4Message, error) {
+	// Fixed BOOTP header is 236 bytes, followed by a 4-byte magic cookie
+	// and then a list of TLV options.
+	const fixedHeaderLen = 236
+	if len(data) < fixedHeaderLen+4 {
+		return nil, errors.New("dhcpsnoop: packet too short")
+	}
+
+	msg := &dhcp4Message{
+		Op:        data[0],
+		YourIP:    net.IPv4(data[16], data[17], data[18], data[19]).To4(),
+		ClientMAC: net.HardwareAddr(data[28:34]),
+	}
+
+	options := data[fixedHeaderLen+4:]
+	for i := 0; i < len(options); {
+		opt := options[i]
+		if opt == dhcpOptionEnd {
+			break
+		}
+		// Pad is a single byte with no length field (RFC 2132 section
+		// 3.1); reading it as a normal TLV would consume the next
+		// option's code as a bogus length and misalign everything after
+		// it.
+		if opt == dhcpOptionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(options) {
+			break
+		}
+		length := int(options[i+1])
+		if i+2+length > len(options) {
+			break
+		}
+		value := options[i+2 : i+2+length]
+
+		switch opt {
+		case dhcpOptionMessageType:
+			if length == 1 {
+				msg.MessageType = value[0]
+			}
+		case dhcpOptionLeaseTime:
+			if length == 4 {
+				seconds := uint32(value[0])<<24 | uint32(value[1])<<16 | uint32(value[2])<<8 | uint32(value[3])
+				msg.LeaseTime = time.Duration(seconds) * time.Second
+			}
+		}
+
+		i += 2 + length
+	}
+
+	return msg, nil
+}
+
+func (r *DHCPSnoop) String() string {
+	return r.Name()
+}
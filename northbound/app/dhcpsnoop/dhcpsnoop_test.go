@@ -0,0 +1,97 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package dhcpsnoop
+
+import (
+	"net"
+	"testing"
+)
+
+// buildDHCP4 assembles a minimal BOOTP packet (RFC 951's 236-byte fixed
+// header plus the 4-byte magic cookie) with the given op, yourIP, client
+// MAC and raw options bytes, as parseDHCP4 expects.
+func buildDHCP4(op byte, yourIP net.IP, mac net.HardwareAddr, options []byte) []byte {
+	packet := make([]byte, 236+4)
+	packet[0] = op
+	copy(packet[16:20], yourIP.To4())
+	copy(packet[28:34], mac)
+	// Magic cookie at packet[236:240] is never inspected by parseDHCP4.
+	return append(packet, options...)
+}
+
+func TestParseDHCP4(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	yourIP := net.IPv4(192, 168, 1, 10)
+	options := []byte{
+		dhcpOptionMessageType, 1, dhcpMessageTypeAck,
+		dhcpOptionLeaseTime, 4, 0x00, 0x00, 0x0e, 0x10, // 3600 seconds
+		dhcpOptionEnd,
+	}
+
+	msg, err := parseDHCP4(buildDHCP4(dhcpOpReply, yourIP, mac, options))
+	if err != nil {
+		t.Fatalf("parseDHCP4 returned an error: %v", err)
+	}
+	if msg.Op != dhcpOpReply {
+		t.Errorf("Op = %v, want %v", msg.Op, dhcpOpReply)
+	}
+	if !msg.YourIP.Equal(yourIP) {
+		t.Errorf("YourIP = %v, want %v", msg.YourIP, yourIP)
+	}
+	if msg.ClientMAC.String() != mac.String() {
+		t.Errorf("ClientMAC = %v, want %v", msg.ClientMAC, mac)
+	}
+	if msg.MessageType != dhcpMessageTypeAck {
+		t.Errorf("MessageType = %v, want %v", msg.MessageType, dhcpMessageTypeAck)
+	}
+	if msg.LeaseTime.Seconds() != 3600 {
+		t.Errorf("LeaseTime = %v, want 3600s", msg.LeaseTime)
+	}
+}
+
+// TestParseDHCP4SkipsPadBytes guards against regressing the option-0 (Pad)
+// handling: RFC 2132 section 3.1 defines it as a single byte with no length
+// field, so a naive TLV reader would consume the option that follows it as
+// a bogus length and misparse everything after.
+func TestParseDHCP4SkipsPadBytes(t *testing.T) {
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	yourIP := net.IPv4(10, 0, 0, 5)
+	options := []byte{
+		dhcpOptionPad, dhcpOptionPad, dhcpOptionPad,
+		dhcpOptionMessageType, 1, dhcpMessageTypeOffer,
+		dhcpOptionEnd,
+	}
+
+	msg, err := parseDHCP4(buildDHCP4(dhcpOpReply, yourIP, mac, options))
+	if err != nil {
+		t.Fatalf("parseDHCP4 returned an error: %v", err)
+	}
+	if msg.MessageType != dhcpMessageTypeOffer {
+		t.Errorf("MessageType = %v, want %v (pad bytes before it should be skipped, not misread as a length)", msg.MessageType, dhcpMessageTypeOffer)
+	}
+}
+
+func TestParseDHCP4TooShort(t *testing.T) {
+	if _, err := parseDHCP4(make([]byte, 10)); err == nil {
+		t.Error("expected an error for a packet shorter than the fixed BOOTP header")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/superkkt/cherry/cluster"
 	"github.com/superkkt/cherry/network"
 	"github.com/superkkt/cherry/northbound/app"
 	"github.com/superkkt/cherry/northbound/util/announcer"
@@ -37,32 +38,77 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/superkkt/go-logging"
+	"golang.org/x/net/context"
 )
 
 var (
 	logger = logging.MustGetLogger("proxyarp")
 )
 
+// broadcasterLease is the cluster-wide singleton name campaigned for so that
+// exactly one node in the cluster emits periodic ARP announcements, even
+// though every node runs its own ProxyARP instance.
+const broadcasterLease = "proxyarp-broadcaster"
+
+const broadcasterLeaseTTL = 10 * time.Second
+
 type ProxyARP struct {
 	app.BaseProcessor
 	db   database
 	once sync.Once
+	// cluster is nil when running standalone, in which case this node is
+	// always the sole broadcaster.
+	cluster cluster.Registry
+	// finder and localNode are both nil/empty together with cluster: they
+	// let OnPacketIn tell whether the switch a request arrived on is one
+	// this node actually owns, and forward to the node that does when it
+	// isn't (see sendARPReply).
+	finder    cluster.Finder
+	forwarder cluster.Forwarder
+	localNode string
 }
 
 type database interface {
 	MAC(ip net.IP) (mac net.HardwareAddr, ok bool, err error)
+	// MAC6 is the IPv6 counterpart of MAC, used by northbound/app/proxynd to
+	// answer Neighbor Solicitations the same way MAC backs ARP replies here.
+	MAC6(ip net.IP) (mac net.HardwareAddr, ok bool, err error)
 	GetActivatedHosts() ([]Host, error)
+	// PutHost and ExpireHost are written by northbound/app/dhcpsnoop as it
+	// observes DHCP lease activity; ProxyARP only reads through MAC and
+	// GetActivatedHosts, but both apps are handed the same database so the
+	// interface needs to describe the whole contract that implementation
+	// must satisfy.
+	PutHost(ip net.IP, mac net.HardwareAddr, ingress uint32, expiry time.Time) error
+	ExpireHost(ip net.IP) error
 }
 
+// Host is a known (IP, MAC) binding. IP holds the host's IPv4 address and
+// IPv6 its IPv6 address; either may be nil if the host only has one of the
+// two; ProxyARP reads only IP, and proxynd reads only IPv6.
 type Host struct {
-	IP  net.IP
-	MAC net.HardwareAddr
+	IP   net.IP
+	IPv6 net.IP
+	MAC  net.HardwareAddr
 }
 
-func New(db database) *ProxyARP {
-	return &ProxyARP{
-		db: db,
+// New returns a ProxyARP backed by db. registry may be nil to run standalone.
+// localNode must be this node's own registry identity (the same string this
+// process passes as the etcd node ID) so OnPacketIn can tell whether it is
+// itself the switch's owner or needs to forward the reply to whichever node
+// is.
+func New(db database, registry cluster.Registry, localNode string) *ProxyARP {
+	r := &ProxyARP{
+		db:        db,
+		cluster:   registry,
+		localNode: localNode,
 	}
+	if registry != nil {
+		r.finder = cluster.NewFinder(registry)
+		r.forwarder = cluster.NewNetForwarder()
+	}
+
+	return r
 }
 
 func (r *ProxyARP) Init() error {
@@ -74,23 +120,68 @@ func (r *ProxyARP) Name() string {
 }
 
 func (r *ProxyARP) OnDeviceUp(finder network.Finder, device *network.Device) error {
-	// Make sure that there is only one ProxyARP broadcaster in this application.
+	// Make sure that there is only one ProxyARP broadcaster goroutine per
+	// process; cluster-wide uniqueness is handled inside the broadcaster
+	// itself via a singleton lease.
 	r.once.Do(func() {
-		// Run the background broadcaster for periodic ARP announcement.
 		go r.broadcaster(finder)
 	})
 
 	return r.BaseProcessor.OnDeviceUp(finder, device)
 }
 
+// broadcaster only emits ARP announcements while this node holds the
+// cluster-wide broadcasterLease, so the periodic announcement comes from
+// exactly one node even though every controller runs a ProxyARP instance.
 func (r *ProxyARP) broadcaster(finder network.Finder) {
 	logger.Debug("executed ARP announcement broadcaster")
 
+	if r.cluster == nil {
+		r.announce(finder)
+		return
+	}
+
+	for {
+		lease, err := r.cluster.Acquire(context.Background(), cluster.SingletonKey(broadcasterLease), broadcasterLeaseTTL)
+		if err != nil {
+			logger.Errorf("failed to acquire broadcaster lease: %v", err)
+			time.Sleep(broadcasterLeaseTTL)
+			continue
+		}
+
+		logger.Debug("this node is now the cluster's ARP announcement broadcaster")
+		stop := make(chan struct{})
+		go func() {
+			select {
+			case <-lease.Released():
+				close(stop)
+			}
+		}()
+		r.announceUntil(finder, stop)
+		logger.Debug("lost the broadcaster lease, standing by")
+	}
+}
+
+// announce runs the periodic ARP announcement loop forever.
+func (r *ProxyARP) announce(finder network.Finder) {
+	r.announceUntil(finder, nil)
+}
+
+// announceUntil runs the periodic ARP announcement loop until stop is
+// closed, or forever if stop is nil.
+func (r *ProxyARP) announceUntil(finder network.Finder, stop <-chan struct{}) {
 	backoff := announcer.NewBackoffARPAnnouncer(finder)
 
-	ticker := time.Tick(5 * time.Second)
-	// Infinite loop.
-	for range ticker {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
 		hosts, err := r.db.GetActivatedHosts()
 		if err != nil {
 			logger.Errorf("failed to get host addresses: %v", err)
@@ -153,6 +244,26 @@ func (r *ProxyARP) OnPacketIn(finder network.Finder, ingress *network.Port, eth
 	}
 	logger.Debugf("sending ARP reply to %v..", ingress.ID())
 
+	return r.sendARPReply(ingress, reply)
+}
+
+// sendARPReply emits reply out of ingress if this node owns that switch, or
+// hands it to cluster.Forwarder for the node that does. A PACKET_IN can
+// arrive on any node holding the switch's TCP connection, not just the
+// active one, so a standby still needs a way to answer it.
+func (r *ProxyARP) sendARPReply(ingress *network.Port, reply []byte) error {
+	if r.finder != nil {
+		dpid := ingress.Device().ID()
+		owner, ok, err := r.finder.Owner(dpid)
+		if err != nil {
+			return err
+		}
+		if ok && owner != r.localNode {
+			logger.Debugf("forwarding ARP reply for dpid=%v to owning node %v", dpid, owner)
+			return r.forwarder.Forward(owner, dpid, ingress.Number(), reply)
+		}
+	}
+
 	return sendARPReply(ingress, reply)
 }
 
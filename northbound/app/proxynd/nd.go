@@ -0,0 +1,309 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package proxynd is the IPv6 counterpart of proxyarp: it intercepts
+// Neighbor Solicitations and answers them out of the same host database,
+// so a dual-stack network can use Cherry as an L2 gateway for both address
+// families.
+package proxynd
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/cluster"
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/northbound/app/proxyarp"
+	"github.com/superkkt/cherry/northbound/util/announcer"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/superkkt/go-logging"
+	"golang.org/x/net/context"
+)
+
+var (
+	logger = logging.MustGetLogger("proxynd")
+)
+
+const (
+	etherTypeIPv6    = 0x86DD
+	nextHeaderICMPv6 = 58
+
+	icmpv6TypeNeighborSolicitation  = 135
+	icmpv6TypeNeighborAdvertisement = 136
+)
+
+const broadcasterLease = "proxynd-broadcaster"
+const broadcasterLeaseTTL = 10 * time.Second
+
+// database is the same shape of store ProxyARP reads from; proxynd only
+// needs the IPv6 half of it.
+type database interface {
+	MAC6(ip net.IP) (mac net.HardwareAddr, ok bool, err error)
+	GetActivatedHosts() ([]proxyarp.Host, error)
+}
+
+// ProxyND answers IPv6 Neighbor Solicitations for hosts it knows about, and
+// periodically emits unsolicited Neighbor Advertisements for them, mirroring
+// ProxyARP's behavior for ARP.
+type ProxyND struct {
+	app.BaseProcessor
+	db      database
+	once    sync.Once
+	cluster cluster.Registry
+	// finder and localNode are both nil/empty together with cluster; see
+	// ProxyARP.sendARPReply, which sendNeighborAdvertisement mirrors for NA.
+	finder    cluster.Finder
+	forwarder cluster.Forwarder
+	localNode string
+}
+
+// New returns a ProxyND backed by db. registry may be nil to run standalone.
+// localNode must be this node's own registry identity, the same value this
+// process passes as the etcd node ID for registry (see ProxyARP.New).
+func New(db database, registry cluster.Registry, localNode string) *ProxyND {
+	r := &ProxyND{
+		db:        db,
+		cluster:   registry,
+		localNode: localNode,
+	}
+	if registry != nil {
+		r.finder = cluster.NewFinder(registry)
+		r.forwarder = cluster.NewNetForwarder()
+	}
+
+	return r
+}
+
+func (r *ProxyND) Init() error {
+	return nil
+}
+
+func (r *ProxyND) Name() string {
+	return "ProxyND"
+}
+
+func (r *ProxyND) OnDeviceUp(finder network.Finder, device *network.Device) error {
+	r.once.Do(func() {
+		go r.broadcaster(finder)
+	})
+
+	return r.BaseProcessor.OnDeviceUp(finder, device)
+}
+
+// broadcaster periodically emits unsolicited Neighbor Advertisements for
+// every known IPv6 host, honouring the same cluster-wide singleton lease
+// and backoff announcer pattern as ProxyARP's broadcaster.
+func (r *ProxyND) broadcaster(finder network.Finder) {
+	logger.Debug("executed NA announcement broadcaster")
+
+	if r.cluster == nil {
+		r.announceUntil(finder, nil)
+		return
+	}
+
+	for {
+		lease, err := r.cluster.Acquire(context.Background(), cluster.SingletonKey(broadcasterLease), broadcasterLeaseTTL)
+		if err != nil {
+			logger.Errorf("failed to acquire broadcaster lease: %v", err)
+			time.Sleep(broadcasterLeaseTTL)
+			continue
+		}
+
+		logger.Debug("this node is now the cluster's NA announcement broadcaster")
+		stop := make(chan struct{})
+		go func() {
+			<-lease.Released()
+			close(stop)
+		}()
+		r.announceUntil(finder, stop)
+		logger.Debug("lost the broadcaster lease, standing by")
+	}
+}
+
+func (r *ProxyND) announceUntil(finder network.Finder, stop <-chan struct{}) {
+	backoff := announcer.NewBackoffNDAnnouncer(finder)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		hosts, err := r.db.GetActivatedHosts()
+		if err != nil {
+			logger.Errorf("failed to get host addresses: %v", err)
+			continue
+		}
+
+		for _, v := range hosts {
+			if v.IPv6 == nil {
+				continue
+			}
+			logger.Debugf("broadcasting an NA announcement for a host: IP=%v, MAC=%v", v.IPv6, v.MAC)
+
+			if err := backoff.Broadcast(v.IPv6, v.MAC); err != nil {
+				logger.Errorf("failed to broadcast an NA announcement: %v", err)
+				continue
+			}
+			time.Sleep(time.Duration(10+rand.Intn(100)) * time.Millisecond)
+		}
+	}
+}
+
+func (r *ProxyND) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+	if eth.Type != etherTypeIPv6 {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	ip6 := new(protocol.IPv6)
+	if err := ip6.UnmarshalBinary(eth.Payload); err != nil {
+		return err
+	}
+	if ip6.NextHeader != nextHeaderICMPv6 {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	icmp6 := new(protocol.ICMPv6)
+	if err := icmp6.UnmarshalBinary(ip6.Payload); err != nil {
+		return err
+	}
+	if icmp6.Type != icmpv6TypeNeighborSolicitation {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	}
+
+	ns := new(protocol.NeighborSolicitation)
+	if err := ns.UnmarshalBinary(icmp6.Payload); err != nil {
+		return err
+	}
+
+	logger.Debugf("received NS packet.. ingress=%v, target=%v", ingress.ID(), ns.TargetAddress)
+
+	mac, ok, err := r.db.MAC6(ns.TargetAddress)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger.Debugf("drop the NS for unknown host (%v)", ns.TargetAddress)
+		return nil
+	}
+
+	na, err := makeNeighborAdvertisement(eth.SrcMAC, ip6, ns, mac)
+	if err != nil {
+		return err
+	}
+
+	return r.sendNeighborAdvertisement(ingress, na)
+}
+
+// sendNeighborAdvertisement mirrors ProxyARP.sendARPReply: it answers
+// locally if this node owns the ingress switch, or forwards to the node
+// that does.
+func (r *ProxyND) sendNeighborAdvertisement(ingress *network.Port, na []byte) error {
+	if r.finder != nil {
+		dpid := ingress.Device().ID()
+		owner, ok, err := r.finder.Owner(dpid)
+		if err != nil {
+			return err
+		}
+		if ok && owner != r.localNode {
+			logger.Debugf("forwarding NA for dpid=%v to owning node %v", dpid, owner)
+			return r.forwarder.Forward(owner, dpid, ingress.Number(), na)
+		}
+	}
+
+	return sendNeighborAdvertisement(ingress, na)
+}
+
+func sendNeighborAdvertisement(ingress *network.Port, packet []byte) error {
+	f := ingress.Device().Factory()
+
+	inPort := openflow.NewInPort()
+	inPort.SetController()
+
+	outPort := openflow.NewOutPort()
+	outPort.SetValue(ingress.Number())
+
+	action, err := f.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetOutPort(outPort)
+
+	out, err := f.NewPacketOut()
+	if err != nil {
+		return err
+	}
+	out.SetInPort(inPort)
+	out.SetAction(action)
+	out.SetData(packet)
+
+	return ingress.Device().SendMessage(out)
+}
+
+func makeNeighborAdvertisement(requestMAC net.HardwareAddr, requestIP *protocol.IPv6, ns *protocol.NeighborSolicitation, mac net.HardwareAddr) ([]byte, error) {
+	na := protocol.NewNeighborAdvertisement(mac, ns.TargetAddress, requestIP.SrcIP)
+	payload, err := na.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	icmp6 := protocol.ICMPv6{
+		Type:    icmpv6TypeNeighborAdvertisement,
+		Payload: payload,
+	}
+	icmp6Bytes, err := icmp6.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	ip6 := protocol.IPv6{
+		SrcIP:      ns.TargetAddress,
+		DstIP:      requestIP.SrcIP,
+		NextHeader: nextHeaderICMPv6,
+		Payload:    icmp6Bytes,
+	}
+	ip6Bytes, err := ip6.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	eth := protocol.Ethernet{
+		SrcMAC:  mac,
+		DstMAC:  requestMAC,
+		Type:    etherTypeIPv6,
+		Payload: ip6Bytes,
+	}
+
+	return eth.MarshalBinary()
+}
+
+func (r *ProxyND) String() string {
+	return r.Name()
+}
@@ -0,0 +1,63 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package announcer builds and floods the gratuitous address announcements
+// (ARP, NDP) the northbound proxy apps periodically broadcast for the hosts
+// they know about, retrying a failed flood a bounded number of times with a
+// backoff before giving up on that announcement.
+package announcer
+
+import (
+	"net"
+	"time"
+
+	"github.com/superkkt/go-logging"
+)
+
+var logger = logging.MustGetLogger("announcer")
+
+// maxFloodAttempts bounds how many times Broadcast retries flooding a
+// single announcement before giving up.
+const maxFloodAttempts = 3
+
+// floodRetryBackoff is the delay between retries of a single flood attempt.
+const floodRetryBackoff = 100 * time.Millisecond
+
+// floodFunc builds the wire packet announcing ip/mac and floods it out
+// every port of every known device.
+type floodFunc func(ip net.IP, mac net.HardwareAddr) error
+
+// withBackoff retries flood up to maxFloodAttempts times, pausing
+// floodRetryBackoff after each failed attempt, so a transient PACKET_OUT failure
+// (e.g. a switch momentarily unreachable) doesn't drop the announcement
+// entirely.
+func withBackoff(flood floodFunc, ip net.IP, mac net.HardwareAddr) error {
+	var err error
+	for i := 0; i < maxFloodAttempts; i++ {
+		if err = flood(ip, mac); err == nil {
+			return nil
+		}
+		logger.Errorf("flood attempt %v/%v failed: %v", i+1, maxFloodAttempts, err)
+		time.Sleep(floodRetryBackoff)
+	}
+
+	return err
+}
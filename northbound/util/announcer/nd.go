@@ -0,0 +1,149 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015 Samjung Data Service, Inc. All rights reserved.
+ * Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package announcer
+
+import (
+	"net"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+)
+
+const (
+	etherTypeIPv6                   = 0x86DD
+	nextHeaderICMPv6                = 58
+	icmpv6TypeNeighborAdvertisement = 136
+)
+
+// allNodesMulticastMAC and allNodesMulticastIPv6 are the well-known IPv6
+// all-nodes multicast addresses every host listens on, used as the
+// destination of an unsolicited Neighbor Advertisement the same way a
+// gratuitous ARP announcement targets ff:ff:ff:ff:ff:ff.
+var (
+	allNodesMulticastMAC  = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+	allNodesMulticastIPv6 = net.ParseIP("ff02::1")
+)
+
+// NDAnnouncer floods an unsolicited Neighbor Advertisement for an IP/MAC
+// binding out every port of every device known to finder, the IPv6
+// counterpart of whatever floods ProxyARP's gratuitous ARP announcements.
+type NDAnnouncer struct {
+	finder network.Finder
+}
+
+// NewBackoffNDAnnouncer returns an NDAnnouncer that floods through finder,
+// retrying a failed flood with a backoff before dropping the announcement,
+// mirroring NewBackoffARPAnnouncer. A flood attempt stops at the first port
+// whose PACKET_OUT fails.
+func NewBackoffNDAnnouncer(finder network.Finder) *NDAnnouncer {
+	return &NDAnnouncer{finder: finder}
+}
+
+// Broadcast floods an unsolicited Neighbor Advertisement announcing that ip
+// now belongs to mac.
+func (a *NDAnnouncer) Broadcast(ip net.IP, mac net.HardwareAddr) error {
+	return withBackoff(a.flood, ip, mac)
+}
+
+func (a *NDAnnouncer) flood(ip net.IP, mac net.HardwareAddr) error {
+	packet, err := makeUnsolicitedAdvertisement(ip, mac)
+	if err != nil {
+		return err
+	}
+
+	for _, device := range a.finder.Devices() {
+		for _, port := range device.Ports() {
+			if err := floodPacketOut(device, port.Number(), packet); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func floodPacketOut(device *network.Device, port uint32, packet []byte) error {
+	f := device.Factory()
+
+	inPort := openflow.NewInPort()
+	inPort.SetController()
+
+	outPort := openflow.NewOutPort()
+	outPort.SetValue(port)
+
+	action, err := f.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetOutPort(outPort)
+
+	out, err := f.NewPacketOut()
+	if err != nil {
+		return err
+	}
+	out.SetInPort(inPort)
+	out.SetAction(action)
+	out.SetData(packet)
+
+	return device.SendMessage(out)
+}
+
+// makeUnsolicitedAdvertisement builds a Neighbor Advertisement announcing
+// ip/mac unprompted, addressed to the all-nodes multicast group rather than
+// a specific requester's address, mirroring a gratuitous ARP reply.
+func makeUnsolicitedAdvertisement(ip net.IP, mac net.HardwareAddr) ([]byte, error) {
+	na := protocol.NewNeighborAdvertisement(mac, ip, allNodesMulticastIPv6)
+	payload, err := na.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	icmp6 := protocol.ICMPv6{
+		Type:    icmpv6TypeNeighborAdvertisement,
+		Payload: payload,
+	}
+	icmp6Bytes, err := icmp6.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	ip6 := protocol.IPv6{
+		SrcIP:      ip,
+		DstIP:      allNodesMulticastIPv6,
+		NextHeader: nextHeaderICMPv6,
+		Payload:    icmp6Bytes,
+	}
+	ip6Bytes, err := ip6.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	eth := protocol.Ethernet{
+		SrcMAC:  mac,
+		DstMAC:  allNodesMulticastMAC,
+		Type:    etherTypeIPv6,
+		Payload: ip6Bytes,
+	}
+
+	return eth.MarshalBinary()
+}